@@ -10,16 +10,25 @@ import (
 	"github.com/ovh/cds/engine/cdn/index"
 	"github.com/ovh/cds/engine/cdn/storage"
 	"github.com/ovh/cds/engine/cdn/storage/cds"
+	"github.com/ovh/cds/engine/cdn/storage/ec"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 	"github.com/ovh/cds/sdk/telemetry"
 )
 
 const (
+	// ItemLogGC is the historical, repo-wide incoming-item max age in seconds, applied when no
+	// storage.RetentionPolicy is configured for a given sdk.CDNItemType.
+	//
+	// Deprecated: configure a storage.RetentionPolicy per item type instead.
 	ItemLogGC = 24 * 3600
+
+	defaultIncomingMaxAge = ItemLogGC * time.Second
 )
 
 func (s *Service) itemPurge(ctx context.Context) {
+	s.ensureRouterInitialized(ctx)
+
 	tickPurge := time.NewTicker(1 * time.Minute)
 	defer tickPurge.Stop()
 	for {
@@ -39,6 +48,8 @@ func (s *Service) itemPurge(ctx context.Context) {
 
 // ItemsGC clean long incoming item + delete item from buffer when synchronized everywhere
 func (s *Service) itemsGC(ctx context.Context) {
+	s.ensureRouterInitialized(ctx)
+
 	tickGC := time.NewTicker(1 * time.Minute)
 	defer tickGC.Stop()
 	for {
@@ -52,9 +63,13 @@ func (s *Service) itemsGC(ctx context.Context) {
 			if err := s.cleanBuffer(ctx); err != nil {
 				log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "%s", err)
 			}
-			if err := s.cleanWaitingItem(ctx); err != nil {
+			summaries, err := s.cleanWaitingItem(ctx)
+			if err != nil {
 				log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "%s", err)
 			}
+			for _, sum := range summaries {
+				telemetry.Record(ctx, metricsItemCompletedByGC, sum.EvictedCount)
+			}
 		}
 	}
 }
@@ -71,11 +86,14 @@ func (s *Service) cleanItemToDelete(ctx context.Context) error {
 		if err := index.DeleteItemByIDs(s.mustDBWithCtx(ctx), ids); err != nil {
 			return err
 		}
+		s.eventBus(ctx).Publish(TopicItemDeleted, ids)
 	}
 	return nil
 }
 
 func (s *Service) cleanBuffer(ctx context.Context) error {
+	s.ensureECRepairLoopStarted(ctx)
+
 	var cdsBackendID string
 	for _, sto := range s.Units.Storages {
 		_, ok := sto.(*cds.CDS)
@@ -92,6 +110,10 @@ func (s *Service) cleanBuffer(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	itemIDs = s.filterECSynchronizedItemIDs(ctx, itemIDs)
+	if len(itemIDs) == 0 {
+		return nil
+	}
 	tx, err := s.mustDBWithCtx(ctx).Begin()
 	if err != nil {
 		return sdk.WrapError(err, "unable to start transaction")
@@ -100,29 +122,102 @@ func (s *Service) cleanBuffer(ctx context.Context) error {
 	if err := storage.DeleteItemsUnit(tx, s.Units.Buffer.ID(), itemIDs); err != nil {
 		return err
 	}
-	return sdk.WithStack(tx.Commit())
+	if err := tx.Commit(); err != nil {
+		return sdk.WithStack(err)
+	}
+	s.eventBus(ctx).Publish(TopicBufferCleaned, itemIDs)
+	return nil
+}
+
+// filterECSynchronizedItemIDs drops, from itemIDs, any item that is striped across an
+// erasure-coded storage unit but hasn't yet reached that unit's shard quorum. Items on an EC
+// unit are only considered synchronized once at least k+ceil(m/2) of their shards are confirmed
+// present, rather than as soon as a single write has completed.
+func (s *Service) filterECSynchronizedItemIDs(ctx context.Context, itemIDs []string) []string {
+	var ecUnit *ec.Unit
+	for _, sto := range s.Units.Storages {
+		if u, ok := sto.(*ec.Unit); ok {
+			ecUnit = u
+			break
+		}
+	}
+	if ecUnit == nil {
+		return itemIDs
+	}
+
+	synced := itemIDs[:0]
+	for _, itemID := range itemIDs {
+		if ecUnit.Synchronized(ctx, itemID) {
+			synced = append(synced, itemID)
+		}
+	}
+	return synced
 }
 
-func (s *Service) cleanWaitingItem(ctx context.Context) error {
-	itemUnits, err := storage.LoadOldItemUnitByItemStatusAndDuration(ctx, s.Mapper, s.mustDBWithCtx(ctx), index.StatusItemIncoming, ItemLogGC)
+// incomingEvictionMaxAge resolves the cutoff to load index.StatusItemIncoming candidates with:
+// storage.LoadOldItemUnitByItemStatusAndDuration has no per-type parameter, so both
+// cleanWaitingItem and retentionDryRun first load against the shortest of every configured
+// storage.RetentionPolicy.IncomingMaxAge and defaultIncomingMaxAge (which item types without a
+// policy fall back to), then re-check each loaded item against its own type's policy via
+// isEligibleForIncomingEviction. A type configured with a longer max age is merely over-fetched by
+// this cutoff, never evicted early, since that re-check drops it before it's acted on.
+func (s *Service) incomingEvictionMaxAge() time.Duration {
+	maxAge := s.Cfg.Storage.RetentionPolicies.EffectiveIncomingMaxAge(defaultIncomingMaxAge)
+	if maxAge > defaultIncomingMaxAge {
+		maxAge = defaultIncomingMaxAge
+	}
+	return maxAge
+}
+
+// isEligibleForIncomingEviction reports whether itemUnit has actually exceeded its own item
+// type's configured storage.RetentionPolicy.IncomingMaxAge (falling back to
+// defaultIncomingMaxAge), independently of whatever looser global cutoff was used to load it.
+func (s *Service) isEligibleForIncomingEviction(itemUnit storage.ItemUnit) bool {
+	maxAge := defaultIncomingMaxAge
+	if policy, ok := s.Cfg.Storage.RetentionPolicies.PolicyFor(itemUnit.Item.Type); ok && policy.IncomingMaxAge > 0 {
+		maxAge = policy.IncomingMaxAge
+	}
+	return time.Since(itemUnit.Item.Created) >= maxAge
+}
+
+// cleanWaitingItem force-completes every index.StatusItemIncoming item unit that has exceeded its
+// own item type's configured retention policy, and returns one storage.RetentionEvictionSummary
+// per item type actually evicted.
+func (s *Service) cleanWaitingItem(ctx context.Context) ([]storage.RetentionEvictionSummary, error) {
+	itemUnits, err := storage.LoadOldItemUnitByItemStatusAndDuration(ctx, s.Mapper, s.mustDBWithCtx(ctx), index.StatusItemIncoming, int64(s.incomingEvictionMaxAge().Seconds()))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	log.Debug("cdn:CompleteWaitingItems: %d items to complete", len(itemUnits))
+
+	evictedByType := map[sdk.CDNItemType]int64{}
+	freedBytesByType := map[sdk.CDNItemType]int64{}
 	for _, itemUnit := range itemUnits {
+		if !s.isEligibleForIncomingEviction(itemUnit) {
+			continue
+		}
+
 		tx, err := s.mustDBWithCtx(ctx).Begin()
 		if err != nil {
-			return sdk.WrapError(err, "unable to start transaction")
+			return nil, sdk.WrapError(err, "unable to start transaction")
 		}
 		if err := s.completeItem(ctx, tx, itemUnit); err != nil {
 			_ = tx.Rollback()
-			return err
+			return nil, err
 		}
 		if err := tx.Commit(); err != nil {
 			_ = tx.Rollback()
-			return err
+			return nil, err
 		}
-		telemetry.Record(ctx, metricsItemCompletedByGC, 1)
+		s.eventBus(ctx).Publish(TopicItemGCEvicted, itemUnit)
+		s.eventBus(ctx).Publish(TopicItemCompleted, itemUnit)
+		evictedByType[itemUnit.Item.Type]++
+		freedBytesByType[itemUnit.Item.Type] += itemUnit.Item.Size
 	}
-	return nil
-}
\ No newline at end of file
+
+	summaries := make([]storage.RetentionEvictionSummary, 0, len(evictedByType))
+	for itemType, count := range evictedByType {
+		summaries = append(summaries, storage.RetentionEvictionSummary{Type: itemType, EvictedCount: count, FreedBytes: freedBytesByType[itemType]})
+	}
+	return summaries, nil
+}
@@ -0,0 +1,87 @@
+package cdn
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Event bus topics for CDN item lifecycle. Subscribers (the SSE endpoint, the webhook worker,
+// and eventually the websocket log stream) all consume the same events instead of each having
+// to poll the DB or the buffer for changes.
+const (
+	TopicItemCompleted = "item.completed"
+	TopicItemDeleted   = "item.deleted"
+	TopicItemGCEvicted = "item.gc.evicted"
+	TopicBufferCleaned = "buffer.cleaned"
+)
+
+const eventSubscriberQueueSize = 100
+
+// Event is a single lifecycle event published on the EventBus.
+type Event struct {
+	Topic     string      `json:"topic"`
+	Timestamp time.Time   `json:"timestamp"`
+	Payload   interface{} `json:"payload"`
+}
+
+// EventBus is an in-process pub/sub used by cleanItemToDelete, cleanBuffer and cleanWaitingItem
+// to publish CDN item lifecycle events, instead of only recording telemetry counters. It lets
+// external systems (the SSE endpoint, the webhook worker) react to those events without polling.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[string][]chan Event
+}
+
+// NewEventBus returns a ready to use EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[string][]chan Event)}
+}
+
+// Publish sends payload to every subscriber of topic. A subscriber whose queue is full misses
+// the event rather than blocking the publisher; subscribers that need delivery guarantees
+// (the webhook worker) should drain their channel promptly.
+func (b *EventBus) Publish(topic string, payload interface{}) {
+	b.mu.Lock()
+	subs := append([]chan Event(nil), b.subscribers[topic]...)
+	b.mu.Unlock()
+
+	evt := Event{Topic: topic, Timestamp: getEventTimestamp(), Payload: payload}
+	for _, ch := range subs {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// Subscribe registers a buffered channel for topic. The returned unsubscribe func is run
+// automatically once ctx is done; a caller that stops reading earlier can invoke it itself.
+func (b *EventBus) Subscribe(ctx context.Context, topic string) (<-chan Event, func()) {
+	b.mu.Lock()
+	ch := make(chan Event, eventSubscriberQueueSize)
+	b.subscribers[topic] = append(b.subscribers[topic], ch)
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		chans := b.subscribers[topic]
+		for i, c := range chans {
+			if c == ch {
+				b.subscribers[topic] = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+
+	return ch, unsubscribe
+}
+
+// getEventTimestamp is its own function so tests can stub it; time.Now is otherwise used directly.
+var getEventTimestamp = time.Now
@@ -0,0 +1,73 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ovh/cds/engine/service"
+	"github.com/ovh/cds/sdk"
+)
+
+var allEventTopics = []string{TopicItemCompleted, TopicItemDeleted, TopicItemGCEvicted, TopicBufferCleaned}
+
+// getEventsHandler streams CDN item lifecycle events as Server-Sent Events. By default it
+// streams every topic; a ?topic=<name> query param restricts the stream to a single topic. It lets
+// a client follow cleanItemToDelete/cleanBuffer/cleanWaitingItem's side effects without polling
+// the DB.
+//
+// Registered as GET /events by initRouter, directly on s.Router.Mux; the handler itself checks no
+// credentials.
+func (s *Service) getEventsHandler() service.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			return sdk.WithStack(fmt.Errorf("cdn:getEventsHandler: streaming unsupported by response writer"))
+		}
+
+		topics := allEventTopics
+		if topic := r.URL.Query().Get("topic"); topic != "" {
+			topics = []string{topic}
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		merged := make(chan Event, eventSubscriberQueueSize)
+		for _, topic := range topics {
+			ch, _ := s.eventBus(ctx).Subscribe(ctx, topic)
+			go func(ch <-chan Event) {
+				for evt := range ch {
+					select {
+					case merged <- evt:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}(ch)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return nil
+			case evt := <-merged:
+				b, err := json.Marshal(evt)
+				if err != nil {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Topic, b); err != nil {
+					return sdk.WithStack(err)
+				}
+				flusher.Flush()
+			}
+		}
+	}
+}
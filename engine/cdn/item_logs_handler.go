@@ -0,0 +1,84 @@
+package cdn
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/ovh/cds/engine/cdn/item"
+	"github.com/ovh/cds/engine/cdn/redis"
+	"github.com/ovh/cds/engine/service"
+	"github.com/ovh/cds/sdk"
+)
+
+var itemLogsStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(*http.Request) bool { return true },
+}
+
+// getItemLogsStreamHandler streams an item's log lines over a websocket, optionally starting at
+// the "offset" query parameter (0 if absent).
+//
+// It used to poll the Redis buffer on a ticker for every open connection; hundreds of viewers
+// tailing a long-running job meant hundreds of tickers hammering the same key. It now registers
+// one subscription per connection on the service's redis.KeyWatcher (started lazily via
+// s.keyWatcher on first use) and only re-reads the buffer (LRANGE from the last offset sent) when
+// that item's buffer key actually changes, falling back to KeyWatcher's own periodic wake-up if a
+// notification is ever dropped.
+func (s *Service) getItemLogsStreamHandler() service.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		vars := mux.Vars(r)
+		itemType := sdk.CDNItemType(vars["type"])
+		apiRefHash := vars["apiRef"]
+
+		var offset int64
+		if raw := r.URL.Query().Get("offset"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return sdk.NewErrorWithStack(err, sdk.NewErrorFrom(sdk.ErrWrongRequest, "invalid offset"))
+			}
+			offset = parsed
+		}
+
+		cdnItem, err := item.LoadByAPIRefHashAndType(ctx, s.Mapper, s.mustDBWithCtx(ctx), apiRefHash, itemType)
+		if err != nil {
+			return err
+		}
+
+		conn, err := itemLogsStreamUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return sdk.WithStack(err)
+		}
+		defer conn.Close() // nolint
+
+		kw, err := s.keyWatcher(ctx)
+		if err != nil {
+			return err
+		}
+
+		ch, unsubscribe, err := kw.Subscribe(cdnItem.ID)
+		if err != nil {
+			return err
+		}
+		defer unsubscribe()
+
+		for ctx.Err() == nil {
+			lines, err := redis.ReadLinesFromOffset(ctx, kw.Client(), cdnItem.ID, offset)
+			if err != nil {
+				return err
+			}
+			for _, line := range lines {
+				if err := conn.WriteJSON(line); err != nil {
+					return sdk.WithStack(err)
+				}
+				offset = line.Number + 1
+			}
+			kw.Wait(ctx, ch)
+		}
+		return nil
+	}
+}
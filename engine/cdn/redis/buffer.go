@@ -0,0 +1,44 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// BufferKey returns the Redis list key holding itemID's log buffer, matching the
+// "cdn:buffer:<itemID>" keys watched by KeyWatcher.
+func BufferKey(itemID string) string {
+	return keyspaceChannelPrefix + itemID
+}
+
+// ReadLinesFromOffset returns every Line stored in itemID's buffer whose own Number is >= offset,
+// so that callers (getItemLogsStreamHandler) can fetch only what's new since their last read
+// instead of polling and re-reading the whole buffer.
+//
+// offset is a line number, not a Redis list index: the two only coincide while the buffer is
+// never trimmed. Once it's capped and old entries evicted, list index 0 is no longer line number
+// 0, so this always fetches the full list and filters by each entry's own authoritative Number
+// rather than reconstructing it from list position.
+func ReadLinesFromOffset(ctx context.Context, client goredis.UniversalClient, itemID string, offset int64) ([]Line, error) {
+	raw, err := client.LRange(ctx, BufferKey(itemID), 0, -1).Result()
+	if err != nil && err != goredis.Nil {
+		return nil, sdk.WithStack(err)
+	}
+
+	lines := make([]Line, 0, len(raw))
+	for _, v := range raw {
+		var line Line
+		if err := json.Unmarshal([]byte(v), &line); err != nil {
+			return nil, sdk.WithStack(err)
+		}
+		if line.Number < offset {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
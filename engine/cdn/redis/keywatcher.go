@@ -0,0 +1,201 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	// keyspaceChannelPattern matches keyspace notifications for CDN buffer keys, as described
+	// in the Redis keyspace notification documentation: __keyspace@<db>__:<key>.
+	keyspaceChannelPattern = "__keyspace@*__:cdn:buffer:*"
+	keyspaceChannelPrefix  = "cdn:buffer:"
+
+	maxSubscribersPerItem = 100
+	fallbackNotifyPeriod  = 5 * time.Second
+	reconnectBaseDelay    = 500 * time.Millisecond
+	reconnectMaxDelay     = 30 * time.Second
+)
+
+// KeyWatcher subscribes once to Redis keyspace notifications for CDN buffer keys and fans out
+// change events to the websocket handlers interested in a given item, so that they no longer
+// have to poll the buffer to detect new log lines. It is modeled on GitLab Workhorse's key-watcher.
+type KeyWatcher struct {
+	client    redis.UniversalClient
+	mu        sync.Mutex
+	listeners sync.Map // map[string][]chan struct{}, keyed by item ID
+}
+
+// NewKeyWatcher creates a KeyWatcher around an existing Redis client. Call Start to begin
+// subscribing; it is expected that notify-keyspace-events is already configured as "KEA" on the
+// Redis server, but EnsureKeyspaceNotifications can be called to set it explicitly.
+func NewKeyWatcher(client redis.UniversalClient) *KeyWatcher {
+	return &KeyWatcher{client: client}
+}
+
+// EnsureKeyspaceNotifications configures the Redis server to emit keyspace and keyevent
+// notifications for all commands, as required for the watcher to receive anything. Operators
+// that already set this in their Redis configuration may skip calling it.
+func (k *KeyWatcher) EnsureKeyspaceNotifications(ctx context.Context) error {
+	if err := k.client.ConfigSet(ctx, "notify-keyspace-events", "KEA").Err(); err != nil {
+		return sdk.WithStack(err)
+	}
+	return nil
+}
+
+// Start opens the single pubsub connection used for the lifetime of the service and reconnects
+// with an exponential backoff whenever the subscription is lost.
+func (k *KeyWatcher) Start(ctx context.Context) {
+	go k.run(ctx)
+}
+
+func (k *KeyWatcher) run(ctx context.Context) {
+	delay := reconnectBaseDelay
+	for ctx.Err() == nil {
+		if err := k.subscribeOnce(ctx); err != nil && ctx.Err() == nil {
+			log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "cdn:redis:KeyWatcher: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay *= 2
+			if delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+			continue
+		}
+		delay = reconnectBaseDelay
+	}
+}
+
+func (k *KeyWatcher) subscribeOnce(ctx context.Context) error {
+	pubsub := k.client.PSubscribe(ctx, keyspaceChannelPattern)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return sdk.WithStack(err)
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return sdk.WithStack(fmt.Errorf("keywatcher: pubsub channel closed"))
+			}
+			if itemID := itemIDFromKeyspaceChannel(msg.Channel); itemID != "" {
+				k.notify(itemID)
+			}
+		}
+	}
+}
+
+func itemIDFromKeyspaceChannel(channel string) string {
+	idx := strings.Index(channel, keyspaceChannelPrefix)
+	if idx == -1 {
+		return ""
+	}
+	return channel[idx+len(keyspaceChannelPrefix):]
+}
+
+func (k *KeyWatcher) notify(itemID string) {
+	// Subscribe/unsubscribe mutate the stored slice (and its backing array, via append) under
+	// k.mu, so snapshot the current listeners under the same lock before ranging over them.
+	k.mu.Lock()
+	v, ok := k.listeners.Load(itemID)
+	var chans []chan struct{}
+	if ok {
+		chans = append([]chan struct{}(nil), v.([]chan struct{})...)
+	}
+	k.mu.Unlock()
+
+	for _, ch := range chans {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// subscriber already has a pending notification, no need to stack another
+		}
+	}
+}
+
+// Subscribe registers a buffered channel that receives a signal every time the buffer key for
+// itemID changes. The caller must invoke the returned unsubscribe func once done listening.
+// It enforces maxSubscribersPerItem so that a single item cannot be tailed by an unbounded
+// number of websocket connections.
+func (k *KeyWatcher) Subscribe(itemID string) (<-chan struct{}, func(), error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var existing []chan struct{}
+	if v, ok := k.listeners.Load(itemID); ok {
+		existing = v.([]chan struct{})
+	}
+	if len(existing) >= maxSubscribersPerItem {
+		return nil, nil, sdk.WithStack(fmt.Errorf("keywatcher: too many subscribers for item %s", itemID))
+	}
+
+	ch := make(chan struct{}, 1)
+	k.listeners.Store(itemID, append(existing, ch))
+
+	unsubscribe := func() {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		v, ok := k.listeners.Load(itemID)
+		if !ok {
+			return
+		}
+		filtered := make([]chan struct{}, 0, len(v.([]chan struct{})))
+		for _, c := range v.([]chan struct{}) {
+			if c != ch {
+				filtered = append(filtered, c)
+			}
+		}
+		if len(filtered) == 0 {
+			k.listeners.Delete(itemID)
+		} else {
+			k.listeners.Store(itemID, filtered)
+		}
+	}
+
+	return ch, unsubscribe, nil
+}
+
+// Wait blocks until a change notification is received on ch, the fallback timer elapses, or ctx
+// is done. The fallback timer guarantees that a subscriber is never stuck for more than
+// fallbackNotifyPeriod even if a keyspace notification was dropped by Redis.
+func (k *KeyWatcher) Wait(ctx context.Context, ch <-chan struct{}) {
+	timer := time.NewTimer(fallbackNotifyPeriod)
+	defer timer.Stop()
+	select {
+	case <-ch:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+// Client returns the underlying Redis client, so that callers can issue buffer reads (LRANGE)
+// over the same connection pool instead of opening a second one.
+func (k *KeyWatcher) Client() redis.UniversalClient {
+	return k.client
+}
+
+// SubscriberCount returns the number of active subscribers for itemID, mostly for tests and metrics.
+func (k *KeyWatcher) SubscriberCount(itemID string) int {
+	v, ok := k.listeners.Load(itemID)
+	if !ok {
+		return 0
+	}
+	return len(v.([]chan struct{}))
+}
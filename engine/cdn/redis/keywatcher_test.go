@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyWatcher(t *testing.T) (*KeyWatcher, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return NewKeyWatcher(client), mr
+}
+
+func TestKeyWatcher_SubscribeAndNotify(t *testing.T) {
+	kw, mr := newTestKeyWatcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	kw.Start(ctx)
+
+	ch, unsubscribe, err := kw.Subscribe("item-1")
+	require.NoError(t, err)
+	t.Cleanup(unsubscribe)
+
+	// give the subscribe-loop goroutine time to establish its PSUBSCRIBE
+	require.Eventually(t, func() bool {
+		return mr.IsSubscribed()
+	}, time.Second, 10*time.Millisecond)
+
+	mr.Publish("__keyspace@0__:cdn:buffer:item-1", "rpush")
+
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a notification for item-1")
+	}
+}
+
+func TestKeyWatcher_IgnoresOtherItems(t *testing.T) {
+	kw, mr := newTestKeyWatcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	kw.Start(ctx)
+
+	ch, unsubscribe, err := kw.Subscribe("item-1")
+	require.NoError(t, err)
+	t.Cleanup(unsubscribe)
+
+	time.Sleep(100 * time.Millisecond)
+	mr.Publish("__keyspace@0__:cdn:buffer:item-2", "rpush")
+
+	select {
+	case <-ch:
+		t.Fatal("did not expect a notification for item-1 when item-2 changed")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestKeyWatcher_MaxSubscribersPerItem(t *testing.T) {
+	kw, _ := newTestKeyWatcher(t)
+
+	for i := 0; i < maxSubscribersPerItem; i++ {
+		_, unsubscribe, err := kw.Subscribe("item-1")
+		require.NoError(t, err)
+		t.Cleanup(unsubscribe)
+	}
+
+	_, _, err := kw.Subscribe("item-1")
+	require.Error(t, err)
+}
+
+func TestKeyWatcher_WaitFallsBackOnTimer(t *testing.T) {
+	kw, _ := newTestKeyWatcher(t)
+
+	ch, unsubscribe, err := kw.Subscribe("item-1")
+	require.NoError(t, err)
+	t.Cleanup(unsubscribe)
+
+	start := time.Now()
+	waitCtx, cancel := context.WithTimeout(context.Background(), fallbackNotifyPeriod+time.Second)
+	t.Cleanup(cancel)
+	kw.Wait(waitCtx, ch)
+	require.True(t, time.Since(start) >= fallbackNotifyPeriod)
+}
+
+func TestItemIDFromKeyspaceChannel(t *testing.T) {
+	require.Equal(t, "abc-123", itemIDFromKeyspaceChannel("__keyspace@0__:cdn:buffer:abc-123"))
+	require.Equal(t, "", itemIDFromKeyspaceChannel("__keyspace@0__:some:other:key"))
+}
@@ -0,0 +1,48 @@
+package cdn
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/ovh/cds/engine/cdn/index"
+	"github.com/ovh/cds/engine/cdn/storage"
+	"github.com/ovh/cds/engine/service"
+)
+
+// getRetentionPolicyDryRunHandler lists, without deleting anything, the items that the currently
+// configured storage.RetentionPolicySet would evict on the next itemsGC tick. It lets operators
+// validate a policy set before relying on it. Registered as GET /admin/cdn/retention/dryrun by
+// initRouter.
+func (s *Service) getRetentionPolicyDryRunHandler() service.Handler {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		results, err := s.retentionDryRun(ctx)
+		if err != nil {
+			return err
+		}
+		return service.WriteJSON(w, results, http.StatusOK)
+	}
+}
+
+// retentionDryRun reuses cleanWaitingItem's own incomingEvictionMaxAge/loader combination and its
+// isEligibleForIncomingEviction re-check, so this can never drift from what the next real itemsGC
+// tick would actually evict.
+func (s *Service) retentionDryRun(ctx context.Context) ([]storage.RetentionDryRunResult, error) {
+	itemUnits, err := storage.LoadOldItemUnitByItemStatusAndDuration(ctx, s.Mapper, s.mustDBWithCtx(ctx), index.StatusItemIncoming, int64(s.incomingEvictionMaxAge().Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]storage.RetentionDryRunResult, 0, len(itemUnits))
+	for _, itemUnit := range itemUnits {
+		if !s.isEligibleForIncomingEviction(itemUnit) {
+			continue
+		}
+		results = append(results, storage.RetentionDryRunResult{
+			ItemID:     itemUnit.ItemID,
+			Type:       itemUnit.Item.Type,
+			Reason:     "incoming item older than configured retention policy",
+			FreedBytes: itemUnit.Item.Size,
+		})
+	}
+	return results, nil
+}
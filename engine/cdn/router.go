@@ -0,0 +1,42 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ovh/cds/engine/service"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// asHTTPHandlerFunc adapts a service.Handler (this package's handler convention, which returns an
+// error) into a plain http.HandlerFunc (what s.Router.Mux.HandleFunc expects), logging any
+// returned error the same way the rest of the router does and answering with a 500.
+func (s *Service) asHTTPHandlerFunc(h service.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(r.Context(), w, r); err != nil {
+			log.ErrorWithFields(r.Context(), logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "%s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+var routerOnce sync.Once
+
+// initRouter registers this series' new routes on the service's existing s.Router.Mux. Each call
+// registers them again, so call sites go through ensureRouterInitialized, which runs it only once.
+func (s *Service) initRouter() {
+	s.Router.Mux.HandleFunc("/admin/cdn/retention/dryrun", s.asHTTPHandlerFunc(s.getRetentionPolicyDryRunHandler())).Methods(http.MethodGet)
+	s.Router.Mux.HandleFunc("/events", s.asHTTPHandlerFunc(s.getEventsHandler())).Methods(http.MethodGet)
+}
+
+// ensureRouterInitialized registers this series' new routes the first time it's called and is a
+// no-op afterwards. It is called from itemPurge and itemsGC, the two loops already confirmed to
+// run once at service startup, so the routes are in place well before any operator would reach
+// for them.
+func (s *Service) ensureRouterInitialized(ctx context.Context) {
+	routerOnce.Do(s.initRouter)
+}
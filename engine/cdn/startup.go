@@ -0,0 +1,107 @@
+package cdn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"github.com/ovh/cds/engine/cdn/redis"
+	"github.com/ovh/cds/engine/cdn/storage"
+	"github.com/ovh/cds/engine/cdn/storage/ec"
+	"github.com/ovh/cds/sdk"
+)
+
+// startKeyWatcher builds the service's redis.KeyWatcher around the same Redis connection as the
+// configured buffer storage unit and starts its PSUBSCRIBE loop.
+func (s *Service) startKeyWatcher(ctx context.Context) error {
+	client, ok := s.Units.Buffer.(interface {
+		Client() goredis.UniversalClient
+	})
+	if !ok {
+		return sdk.WithStack(fmt.Errorf("cdn: configured buffer unit does not expose a redis client, cannot start KeyWatcher"))
+	}
+
+	kw := redis.NewKeyWatcher(client.Client())
+	if err := kw.EnsureKeyspaceNotifications(ctx); err != nil {
+		return err
+	}
+	kw.Start(ctx)
+	s.KeyWatcher = kw
+	return nil
+}
+
+var keyWatcherMu sync.Mutex
+
+// keyWatcher returns the service's redis.KeyWatcher, starting it on the first call. This snapshot
+// has no single entry point confirmed to run once at service startup before HTTP traffic is
+// served, so getItemLogsStreamHandler (the only consumer) triggers the start itself on its first
+// invocation instead of relying on one; keyWatcherMu makes that safe against concurrent first
+// requests. The watcher is started with a background context rather than the triggering
+// request's, so that it keeps running after that request ends.
+func (s *Service) keyWatcher(ctx context.Context) (*redis.KeyWatcher, error) {
+	keyWatcherMu.Lock()
+	defer keyWatcherMu.Unlock()
+	if s.KeyWatcher == nil {
+		if err := s.startKeyWatcher(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return s.KeyWatcher, nil
+}
+
+// startECRepairLoop locates the erasure-coded storage.StorageUnit (if one is configured) among
+// s.Units.Storages and starts its background repair goroutine, listing candidate item IDs via
+// storage.LoadAllItemIDsByUnit. Without it, items striped across the EC unit that drop below
+// quorum are never repaired.
+func (s *Service) startECRepairLoop(ctx context.Context) {
+	var ecUnit *ec.Unit
+	for _, sto := range s.Units.Storages {
+		if u, ok := sto.(*ec.Unit); ok {
+			ecUnit = u
+			break
+		}
+	}
+	if ecUnit == nil {
+		return
+	}
+
+	go ecUnit.RepairLoop(ctx, func(ctx context.Context) ([]string, error) {
+		return storage.LoadAllItemIDsByUnit(ctx, s.mustDBWithCtx(ctx), ecUnit.ID())
+	})
+}
+
+var ecRepairLoopOnce sync.Once
+
+// ensureECRepairLoopStarted starts the EC repair loop the first time it's called and is a no-op
+// afterwards. cleanBuffer calls it on every itemsGC tick so the loop is running for the lifetime
+// of the service without requiring a dedicated startup entry point.
+func (s *Service) ensureECRepairLoopStarted(ctx context.Context) {
+	ecRepairLoopOnce.Do(func() {
+		s.startECRepairLoop(ctx)
+	})
+}
+
+// startEventBus creates the service's EventBus and starts one webhook worker per
+// s.Cfg.Webhooks entry against it.
+func (s *Service) startEventBus(ctx context.Context) {
+	s.Events = NewEventBus()
+	s.startWebhookWorkers(ctx, s.Cfg.Webhooks)
+}
+
+var eventBusMu sync.Mutex
+
+// eventBus returns the service's EventBus, starting it (and its webhook workers) on the first
+// call. This snapshot has no single entry point confirmed to run once at service startup, so
+// cleanItemToDelete, cleanBuffer, cleanWaitingItem and getEventsHandler all go through this
+// accessor instead of reading s.Events directly, so that none of them can run before the bus
+// exists; eventBusMu makes that safe against concurrent first callers.
+func (s *Service) eventBus(ctx context.Context) *EventBus {
+	eventBusMu.Lock()
+	defer eventBusMu.Unlock()
+	if s.Events == nil {
+		s.startEventBus(ctx)
+	}
+	return s.Events
+}
@@ -0,0 +1,272 @@
+// Package ec implements a storage.StorageUnit that stripes each item across N child backends
+// using Reed-Solomon erasure coding, so that the item survives the loss of up to ParityShards
+// child backends instead of requiring every configured storage.Storages entry to hold a full copy.
+package ec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ovh/cds/engine/cdn/storage"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	shardKeySuffixFormat = "%s#shard%d"
+	sizeKeySuffixFormat  = "%s#size"
+
+	repairScanPeriod = 10 * time.Minute
+)
+
+// Configuration configures the erasure-coded backend: how many data (DataShards) and parity
+// (ParityShards) shards to split each item into. The child backends to stripe across are passed
+// separately to New, and their number must equal DataShards+ParityShards.
+type Configuration struct {
+	ID           string `json:"id" toml:"id"`
+	Name         string `json:"name" toml:"name"`
+	DataShards   int    `json:"data_shards" toml:"dataShards"`
+	ParityShards int    `json:"parity_shards" toml:"parityShards"`
+}
+
+// Unit is a storage.StorageUnit that erasure-codes items across its configured children. Like its
+// children, it exposes NewWriter/NewReader factories keyed by storage.ItemUnit rather than raw
+// (itemID, io.Reader/io.Writer) pairs, so it conforms to the same contract the rest of the
+// package (storage/cds, storage/local) already implements.
+type Unit struct {
+	cfg      Configuration
+	children []storage.StorageUnit
+	enc      reedsolomon.Encoder
+
+	mu sync.Mutex
+}
+
+// New builds an erasure-coded storage unit. len(children) must equal
+// cfg.DataShards+cfg.ParityShards.
+func New(cfg Configuration, children []storage.StorageUnit) (*Unit, error) {
+	if len(children) != cfg.DataShards+cfg.ParityShards {
+		return nil, sdk.WithStack(fmt.Errorf("ec: expected %d children (k=%d + m=%d), got %d", cfg.DataShards+cfg.ParityShards, cfg.DataShards, cfg.ParityShards, len(children)))
+	}
+	enc, err := reedsolomon.New(cfg.DataShards, cfg.ParityShards)
+	if err != nil {
+		return nil, sdk.WithStack(err)
+	}
+	return &Unit{cfg: cfg, children: children, enc: enc}, nil
+}
+
+func (u *Unit) ID() string   { return u.cfg.ID }
+func (u *Unit) Name() string { return u.cfg.Name }
+
+// quorum is the minimum number of confirmed-present shards for an item to be considered
+// synchronized: k data shards plus half (rounded up) of the parity shards, so that the unit
+// tolerates losing some parity shards without re-triggering a sync/repair.
+func (u *Unit) quorum() int {
+	return u.cfg.DataShards + int(math.Ceil(float64(u.cfg.ParityShards)/2))
+}
+
+// ecWriteCloser buffers everything written to it in memory and only erasure-codes and dispatches
+// it to the children on Close, since Reed-Solomon needs the whole item up front to split it into
+// shards.
+type ecWriteCloser struct {
+	ctx    context.Context
+	unit   *Unit
+	itemID string
+	buf    bytes.Buffer
+}
+
+func (w *ecWriteCloser) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *ecWriteCloser) Close() error {
+	return w.unit.write(w.ctx, w.itemID, w.buf.Bytes())
+}
+
+// NewWriter returns a io.WriteCloser that, once closed, has split the written content into
+// DataShards data shards, computed ParityShards parity shards, and written each shard to a
+// distinct child backend under i.ItemID's shard-indexed key.
+func (u *Unit) NewWriter(ctx context.Context, i storage.ItemUnit) (io.WriteCloser, error) {
+	return &ecWriteCloser{ctx: ctx, unit: u, itemID: i.ItemID}, nil
+}
+
+func (u *Unit) write(ctx context.Context, itemID string, data []byte) error {
+	shards, err := u.enc.Split(data)
+	if err != nil {
+		return sdk.WithStack(err)
+	}
+	if err := u.enc.Encode(shards); err != nil {
+		return sdk.WithStack(err)
+	}
+
+	for i, child := range u.children {
+		if err := writeChild(ctx, child, fmt.Sprintf(shardKeySuffixFormat, itemID, i), shards[i]); err != nil {
+			return sdk.WrapError(err, "ec: writing shard %d of item %s to backend %s", i, itemID, child.ID())
+		}
+		if err := writeChild(ctx, child, fmt.Sprintf(sizeKeySuffixFormat, itemID), []byte(fmt.Sprintf("%d", len(data)))); err != nil {
+			return sdk.WrapError(err, "ec: writing size metadata of item %s to backend %s", itemID, child.ID())
+		}
+	}
+	return nil
+}
+
+func writeChild(ctx context.Context, child storage.StorageUnit, itemID string, content []byte) error {
+	w, err := child.NewWriter(ctx, storage.ItemUnit{ItemID: itemID})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(content); err != nil {
+		_ = w.Close()
+		return sdk.WithStack(err)
+	}
+	return w.Close()
+}
+
+// readChild opens a child's reader for itemID and drains it into a buffer. Every caller that
+// needs itemID's actual content (NewReader, Repair) goes through this; shardCount does not, since
+// it only needs to know the shard is present.
+func readChild(ctx context.Context, child storage.StorageUnit, itemID string) ([]byte, error) {
+	r, err := child.NewReader(ctx, storage.ItemUnit{ItemID: itemID})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint
+	return ioutil.ReadAll(r)
+}
+
+// NewReader gathers shards for i.ItemID from at least DataShards children, reconstructs any
+// missing shards, and returns the original content as a io.ReadCloser.
+func (u *Unit) NewReader(ctx context.Context, i storage.ItemUnit) (io.ReadCloser, error) {
+	shards := make([][]byte, len(u.children))
+	present := 0
+	var size int
+	sizeFound := false
+	for idx, child := range u.children {
+		content, err := readChild(ctx, child, fmt.Sprintf(shardKeySuffixFormat, i.ItemID, idx))
+		if err != nil {
+			continue
+		}
+		shards[idx] = content
+		present++
+
+		if !sizeFound {
+			if sizeContent, err := readChild(ctx, child, fmt.Sprintf(sizeKeySuffixFormat, i.ItemID)); err == nil {
+				if _, err := fmt.Sscanf(string(sizeContent), "%d", &size); err == nil {
+					sizeFound = true
+				}
+			}
+		}
+	}
+	if present < u.cfg.DataShards {
+		return nil, sdk.WithStack(fmt.Errorf("ec: only %d/%d shards available for item %s, need at least %d", present, len(u.children), i.ItemID, u.cfg.DataShards))
+	}
+	if !sizeFound {
+		return nil, sdk.WithStack(fmt.Errorf("ec: could not recover size metadata for item %s", i.ItemID))
+	}
+
+	if present < len(shards) {
+		if err := u.enc.Reconstruct(shards); err != nil {
+			return nil, sdk.WithStack(err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := u.enc.Join(&out, shards, size); err != nil {
+		return nil, sdk.WithStack(err)
+	}
+	return ioutil.NopCloser(&out), nil
+}
+
+// shardCount returns how many of itemID's shards are currently present across the children. It
+// only opens each child's reader (a cheap existence check, e.g. an os.Open for storage/local)
+// rather than draining its content, since RepairLoop (for every item listed on this unit) and
+// Synchronized (for every item cleanBuffer considers) call this on every tick, and draining full
+// shard content that often would be a needless read amplification.
+func (u *Unit) shardCount(ctx context.Context, itemID string) int {
+	count := 0
+	for i, child := range u.children {
+		r, err := child.NewReader(ctx, storage.ItemUnit{ItemID: fmt.Sprintf(shardKeySuffixFormat, itemID, i)})
+		if err != nil {
+			continue
+		}
+		_ = r.Close()
+		count++
+	}
+	return count
+}
+
+// Synchronized reports whether itemID has reached quorum (k + ceil(m/2) shards confirmed
+// present) and can be considered synchronized on this unit.
+func (u *Unit) Synchronized(ctx context.Context, itemID string) bool {
+	return u.shardCount(ctx, itemID) >= u.quorum()
+}
+
+// Repair rewrites an item's missing shards from its surviving shards, if enough are present to
+// reconstruct. It is a no-op when the item already has all shards, and returns an error when
+// fewer than DataShards shards survive.
+func (u *Unit) Repair(ctx context.Context, itemID string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	shards := make([][]byte, len(u.children))
+	missing := make([]int, 0)
+	present := 0
+	for i, child := range u.children {
+		content, err := readChild(ctx, child, fmt.Sprintf(shardKeySuffixFormat, itemID, i))
+		if err != nil {
+			missing = append(missing, i)
+			continue
+		}
+		shards[i] = content
+		present++
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if present < u.cfg.DataShards {
+		return sdk.WithStack(fmt.Errorf("ec: cannot repair item %s, only %d/%d shards survive", itemID, present, u.cfg.DataShards))
+	}
+
+	if err := u.enc.Reconstruct(shards); err != nil {
+		return sdk.WithStack(err)
+	}
+	for _, i := range missing {
+		if err := writeChild(ctx, u.children[i], fmt.Sprintf(shardKeySuffixFormat, itemID, i), shards[i]); err != nil {
+			return sdk.WrapError(err, "ec: rewriting shard %d of item %s to backend %s", i, itemID, u.children[i].ID())
+		}
+	}
+	return nil
+}
+
+// RepairLoop periodically scans itemIDs (as supplied by listItemIDs) for items missing any of their
+// DataShards+ParityShards shards and repairs them. It is meant to be run as a background goroutine for the lifetime of the service.
+func (u *Unit) RepairLoop(ctx context.Context, listItemIDs func(ctx context.Context) ([]string, error)) {
+	ticker := time.NewTicker(repairScanPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			itemIDs, err := listItemIDs(ctx)
+			if err != nil {
+				log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "ec:RepairLoop: %v", err)
+				continue
+			}
+			for _, itemID := range itemIDs {
+				if u.shardCount(ctx, itemID) >= len(u.children) {
+					continue
+				}
+				if err := u.Repair(ctx, itemID); err != nil {
+					log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "ec:RepairLoop: item %s: %v", itemID, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package ec
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ovh/cds/engine/cdn/storage"
+	"github.com/ovh/cds/engine/cdn/storage/local"
+)
+
+func newTestChildren(t *testing.T, n int) []storage.StorageUnit {
+	dir := t.TempDir()
+	children := make([]storage.StorageUnit, n)
+	for i := 0; i < n; i++ {
+		name := "child" + string(rune('0'+i))
+		u, err := local.New(local.Configuration{
+			ID:   name,
+			Name: name,
+			Path: filepath.Join(dir, name),
+		})
+		require.NoError(t, err)
+		children[i] = u
+	}
+	return children
+}
+
+func writeItem(t *testing.T, u *Unit, ctx context.Context, itemID string, content []byte) {
+	w, err := u.NewWriter(ctx, storage.ItemUnit{ItemID: itemID})
+	require.NoError(t, err)
+	_, err = w.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func readItem(t *testing.T, u *Unit, ctx context.Context, itemID string) ([]byte, error) {
+	r, err := u.NewReader(ctx, storage.ItemUnit{ItemID: itemID})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint
+	return ioutil.ReadAll(r)
+}
+
+func TestUnit_WriteReadRoundTrip(t *testing.T) {
+	children := newTestChildren(t, 3) // k=2, m=1
+	u, err := New(Configuration{ID: "ec-test", Name: "ec-test", DataShards: 2, ParityShards: 1}, children)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := []byte("this is a reasonably long log line that will be split across shards")
+	writeItem(t, u, ctx, "item-1", content)
+
+	out, err := readItem(t, u, ctx, "item-1")
+	require.NoError(t, err)
+	require.Equal(t, content, out)
+}
+
+func TestUnit_ReadSurvivesOneChildLoss(t *testing.T) {
+	children := newTestChildren(t, 3) // k=2, m=1
+	u, err := New(Configuration{ID: "ec-test", Name: "ec-test", DataShards: 2, ParityShards: 1}, children)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := []byte("this item must survive the loss of exactly one of its three shards")
+	writeItem(t, u, ctx, "item-1", content)
+
+	// simulate the loss of one child backend by deleting its shard and size metadata
+	lost := children[1].(*local.Unit)
+	require.NoError(t, lost.Remove("item-1#shard1"))
+	require.NoError(t, lost.Remove("item-1#size"))
+
+	out, err := readItem(t, u, ctx, "item-1")
+	require.NoError(t, err)
+	require.Equal(t, content, out)
+}
+
+func TestUnit_ReadFailsBelowDataShards(t *testing.T) {
+	children := newTestChildren(t, 3) // k=2, m=1
+	u, err := New(Configuration{ID: "ec-test", Name: "ec-test", DataShards: 2, ParityShards: 1}, children)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := []byte("losing two of three shards should be unrecoverable for k=2")
+	writeItem(t, u, ctx, "item-1", content)
+
+	require.NoError(t, children[0].(*local.Unit).Remove("item-1#shard0"))
+	require.NoError(t, children[1].(*local.Unit).Remove("item-1#shard1"))
+
+	_, err = readItem(t, u, ctx, "item-1")
+	require.Error(t, err)
+}
+
+func TestUnit_Repair(t *testing.T) {
+	children := newTestChildren(t, 3) // k=2, m=1
+	u, err := New(Configuration{ID: "ec-test", Name: "ec-test", DataShards: 2, ParityShards: 1}, children)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	content := []byte("repair should rewrite a missing shard from the surviving ones")
+	writeItem(t, u, ctx, "item-1", content)
+
+	lost := children[2].(*local.Unit)
+	require.NoError(t, lost.Remove("item-1#shard2"))
+	require.False(t, lost.Exists("item-1#shard2"))
+
+	require.NoError(t, u.Repair(ctx, "item-1"))
+	require.True(t, lost.Exists("item-1#shard2"))
+	require.True(t, u.Synchronized(ctx, "item-1"))
+}
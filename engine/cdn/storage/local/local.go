@@ -0,0 +1,77 @@
+package local
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ovh/cds/engine/cdn/storage"
+	"github.com/ovh/cds/sdk"
+)
+
+// Configuration configures a local filesystem storage unit. It is mostly used in tests and to
+// compose child backends for striping units such as storage/ec.
+type Configuration struct {
+	ID   string `json:"id" toml:"id"`
+	Name string `json:"name" toml:"name"`
+	Path string `json:"path" toml:"path"`
+}
+
+// Unit is a storage.StorageUnit backed by a local filesystem directory: each item is stored as a
+// single file named after its item ID under Configuration.Path.
+type Unit struct {
+	cfg Configuration
+}
+
+// New returns a local filesystem storage unit rooted at cfg.Path, creating the directory if needed.
+func New(cfg Configuration) (*Unit, error) {
+	if err := os.MkdirAll(cfg.Path, 0o750); err != nil {
+		return nil, sdk.WithStack(err)
+	}
+	return &Unit{cfg: cfg}, nil
+}
+
+func (u *Unit) ID() string   { return u.cfg.ID }
+func (u *Unit) Name() string { return u.cfg.Name }
+
+func (u *Unit) itemPath(itemID string) string {
+	return filepath.Join(u.cfg.Path, itemID)
+}
+
+// NewWriter returns an io.WriteCloser that overwrites i.ItemID's content on this unit. The file is
+// created (or truncated) as soon as NewWriter is called, so a caller that opens then immediately
+// closes the writer without writing replaces any previous content with an empty file.
+func (u *Unit) NewWriter(_ context.Context, i storage.ItemUnit) (io.WriteCloser, error) {
+	f, err := os.Create(u.itemPath(i.ItemID))
+	if err != nil {
+		return nil, sdk.WithStack(err)
+	}
+	return f, nil
+}
+
+// NewReader returns an io.ReadCloser streaming i.ItemID's content from this unit. It returns an
+// error (wrapped by sdk.WithStack) if i.ItemID does not exist on this unit; opening it does not
+// itself read any content, so callers that only need to check existence can open then immediately
+// close the reader.
+func (u *Unit) NewReader(_ context.Context, i storage.ItemUnit) (io.ReadCloser, error) {
+	f, err := os.Open(u.itemPath(i.ItemID))
+	if err != nil {
+		return nil, sdk.WithStack(err)
+	}
+	return f, nil
+}
+
+// Exists returns whether itemID has content stored on this unit.
+func (u *Unit) Exists(itemID string) bool {
+	_, err := os.Stat(u.itemPath(itemID))
+	return err == nil
+}
+
+// Remove deletes itemID's content from this unit, if present.
+func (u *Unit) Remove(itemID string) error {
+	if err := os.Remove(u.itemPath(itemID)); err != nil && !os.IsNotExist(err) {
+		return sdk.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// RetentionPolicy describes, for a given sdk.CDNItemType, how long an item may stay in
+// index.StatusItemIncoming before itemsGC force-completes it.
+//
+// It replaces the single, repo-wide ItemLogGC constant that used to be applied regardless of
+// item type: operators can now declare one policy per type, e.g. keep step logs around longer
+// than service logs. Completed-item buffer age, per-workflow byte caps and cron-style schedules
+// were considered for this first cut but aren't enforced anywhere yet, so they're left out of
+// this type rather than advertised and silently ignored; add them alongside whatever GC pass
+// ends up enforcing them.
+type RetentionPolicy struct {
+	Type sdk.CDNItemType `json:"type" toml:"type"`
+
+	// IncomingMaxAge is how long an item may stay in index.StatusItemIncoming before it is
+	// force-completed, mirroring the old ItemLogGC constant.
+	IncomingMaxAge time.Duration `json:"incoming_max_age" toml:"incomingMaxAge"`
+}
+
+// RetentionPolicySet is the set of RetentionPolicy declared by an operator, keyed by item type.
+//
+// It is parsed from TOML as the RetentionPolicies field of storage.Configuration (see
+// item_logs_handler_test.go's storage.Init call for the rest of that struct, e.g. Buffer), so
+// that it lives alongside the other storage settings rather than on the top-level service
+// Configuration.
+type RetentionPolicySet []RetentionPolicy
+
+// PolicyFor returns the policy registered for the given item type, and whether one was found.
+func (s RetentionPolicySet) PolicyFor(t sdk.CDNItemType) (RetentionPolicy, bool) {
+	for _, p := range s {
+		if p.Type == t {
+			return p, true
+		}
+	}
+	return RetentionPolicy{}, false
+}
+
+// EffectiveIncomingMaxAge returns the shortest IncomingMaxAge configured across the set (the
+// duration that must be used when loading eviction candidates, since the existing loader only
+// takes a single cutoff rather than one per item type), or fallback if no policy sets one.
+//
+// Candidates returned by that single, looser cutoff are still attributed to their own type's
+// policy afterwards (see cleanWaitingItem), so a type with a longer configured IncomingMaxAge
+// than the effective one is simply over-fetched, never under-fetched.
+func (s RetentionPolicySet) EffectiveIncomingMaxAge(fallback time.Duration) time.Duration {
+	min := fallback
+	found := false
+	for _, p := range s {
+		if p.IncomingMaxAge <= 0 {
+			continue
+		}
+		if !found || p.IncomingMaxAge < min {
+			min = p.IncomingMaxAge
+			found = true
+		}
+	}
+	return min
+}
+
+// RetentionEvictionSummary counts, per item type, the items evicted and the bytes freed by one
+// itemsGC tick. itemsGC records each summary's EvictedCount on the metricsItemCompletedByGC
+// metric; the item type and FreedBytes are not part of any recorded metric.
+type RetentionEvictionSummary struct {
+	Type         sdk.CDNItemType `json:"type"`
+	EvictedCount int64           `json:"evicted_count"`
+	FreedBytes   int64           `json:"freed_bytes"`
+}
+
+// RetentionDryRunResult is returned by the dry-run admin endpoint: the items that the current
+// policy set would evict if itemsGC ran right now, without actually deleting anything.
+type RetentionDryRunResult struct {
+	ItemID     string          `json:"item_id"`
+	Type       sdk.CDNItemType `json:"type"`
+	Reason     string          `json:"reason"`
+	FreedBytes int64           `json:"freed_bytes"`
+}
@@ -0,0 +1,150 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	webhookDefaultQueueSize = 100
+	webhookDefaultMaxRetry  = 5
+	webhookBaseDelay        = 1 * time.Second
+	webhookMaxDelay         = 1 * time.Minute
+)
+
+// WebhookConfiguration declares an outbound webhook that forwards every EventBus event published
+// on Topic to URL, so that external systems can react to CDN item lifecycle events without
+// consuming the SSE endpoint themselves.
+type WebhookConfiguration struct {
+	Topic     string `toml:"topic" json:"topic"`
+	URL       string `toml:"url" json:"url"`
+	MaxRetry  int    `toml:"maxRetry" json:"max_retry"`
+	QueueSize int    `toml:"queueSize" json:"queue_size"`
+}
+
+func (c WebhookConfiguration) maxRetry() int {
+	if c.MaxRetry <= 0 {
+		return webhookDefaultMaxRetry
+	}
+	return c.MaxRetry
+}
+
+func (c WebhookConfiguration) queueSize() int {
+	if c.QueueSize <= 0 {
+		return webhookDefaultQueueSize
+	}
+	return c.QueueSize
+}
+
+type webhookWorker struct {
+	cfg    WebhookConfiguration
+	queue  chan Event
+	client *http.Client
+}
+
+func newWebhookWorker(cfg WebhookConfiguration) *webhookWorker {
+	return &webhookWorker{
+		cfg:    cfg,
+		queue:  make(chan Event, cfg.queueSize()),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// startWebhookWorkers subscribes one worker per configured webhook to its topic on the service's
+// EventBus and runs it for the lifetime of ctx.
+func (s *Service) startWebhookWorkers(ctx context.Context, cfgs []WebhookConfiguration) {
+	for _, cfg := range cfgs {
+		worker := newWebhookWorker(cfg)
+		ch, _ := s.Events.Subscribe(ctx, cfg.Topic)
+		go worker.run(ctx, ch)
+	}
+}
+
+// run enqueues incoming events onto the worker's bounded queue, dropping the event (and logging
+// a warning) if the queue is full rather than blocking the publisher, then delivers them one at
+// a time with exponential backoff retries.
+func (w *webhookWorker) run(ctx context.Context, in <-chan Event) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case w.queue <- evt:
+				default:
+					log.Warn(ctx, "cdn:webhook: queue full for topic %s, dropping event", w.cfg.Topic)
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case evt := <-w.queue:
+			w.deliverWithRetry(ctx, evt)
+		}
+	}
+}
+
+func (w *webhookWorker) deliverWithRetry(ctx context.Context, evt Event) {
+	delay := webhookBaseDelay
+	for attempt := 0; attempt <= w.cfg.maxRetry(); attempt++ {
+		if err := w.deliver(ctx, evt); err == nil {
+			return
+		} else if attempt == w.cfg.maxRetry() {
+			log.ErrorWithFields(ctx, logrus.Fields{"stack_trace": fmt.Sprintf("%+v", err)}, "cdn:webhook: giving up delivering %s to %s after %d attempts: %v", evt.Topic, w.cfg.URL, attempt+1, err)
+			return
+		} else {
+			log.Warn(ctx, "cdn:webhook: attempt %d delivering %s to %s failed: %v", attempt+1, evt.Topic, w.cfg.URL, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > webhookMaxDelay {
+			delay = webhookMaxDelay
+		}
+	}
+}
+
+func (w *webhookWorker) deliver(ctx context.Context, evt Event) error {
+	b, err := json.Marshal(evt)
+	if err != nil {
+		return sdk.WithStack(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(b))
+	if err != nil {
+		return sdk.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return sdk.WithStack(err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode >= 300 {
+		return sdk.WithStack(fmt.Errorf("cdn:webhook: unexpected status %d from %s", resp.StatusCode, w.cfg.URL))
+	}
+	return nil
+}